@@ -0,0 +1,128 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pglogrepl"
+)
+
+// Checkpointer persists the flushed LSN so a Subscriber can resume
+// streaming after a restart without replaying transactions the handler has
+// already processed (beyond Config.WalRetain).
+type Checkpointer interface {
+	// Load returns the last durably saved LSN, or zero if none has been
+	// saved yet.
+	Load() (pglogrepl.LSN, error)
+	// Save durably persists lsn as the new checkpoint.
+	Save(lsn pglogrepl.LSN) error
+	// Close releases any resources (connections, file handles) held by the
+	// Checkpointer. It is safe to call even if the Checkpointer holds none.
+	Close(ctx context.Context) error
+}
+
+// FileCheckpointer persists the checkpoint as the text LSN representation
+// in a local file, written via a rename so a crash mid-write cannot leave a
+// truncated checkpoint behind.
+type FileCheckpointer struct {
+	Path string
+}
+
+// NewFileCheckpointer returns a Checkpointer backed by the file at path.
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{Path: path}
+}
+
+// Load implements Checkpointer.
+func (f *FileCheckpointer) Load() (pglogrepl.LSN, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("cdc: load checkpoint from %s: %w", f.Path, err)
+	}
+
+	lsn, err := pglogrepl.ParseLSN(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("cdc: parse checkpoint in %s: %w", f.Path, err)
+	}
+	return lsn, nil
+}
+
+// Save implements Checkpointer.
+func (f *FileCheckpointer) Save(lsn pglogrepl.LSN) error {
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(lsn.String()), 0o644); err != nil {
+		return fmt.Errorf("cdc: save checkpoint to %s: %w", f.Path, err)
+	}
+	if err := os.Rename(tmp, f.Path); err != nil {
+		return fmt.Errorf("cdc: save checkpoint to %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// Close implements Checkpointer. FileCheckpointer holds no open resources
+// between calls, so this is a no-op.
+func (f *FileCheckpointer) Close(ctx context.Context) error {
+	return nil
+}
+
+// PostgresCheckpointer persists the checkpoint in a Postgres table, keyed by
+// slot name, over its own non-replication connection.
+type PostgresCheckpointer struct {
+	conn     *pgconn.PgConn
+	table    string
+	slotName string
+}
+
+// NewPostgresCheckpointer connects to dsn (a regular, non-replication DSN)
+// and ensures the checkpoint table exists.
+func NewPostgresCheckpointer(ctx context.Context, dsn, table, slotName string) (*PostgresCheckpointer, error) {
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("cdc: connect checkpoint store: %w", err)
+	}
+
+	pc := &PostgresCheckpointer{conn: conn, table: table, slotName: slotName}
+
+	ddl := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (slot_name text PRIMARY KEY, lsn text NOT NULL)`, quoteIdent(pc.table))
+	if _, err := conn.Exec(ctx, ddl).ReadAll(); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("cdc: create checkpoint table: %w", err)
+	}
+
+	return pc, nil
+}
+
+// Load implements Checkpointer.
+func (p *PostgresCheckpointer) Load() (pglogrepl.LSN, error) {
+	sql := fmt.Sprintf(`SELECT lsn FROM %s WHERE slot_name = $1`, quoteIdent(p.table))
+	result := p.conn.ExecParams(context.Background(), sql, [][]byte{[]byte(p.slotName)}, nil, nil, nil).Read()
+	if result.Err != nil {
+		return 0, fmt.Errorf("cdc: load checkpoint: %w", result.Err)
+	}
+	if len(result.Rows) == 0 {
+		return 0, nil
+	}
+	return pglogrepl.ParseLSN(string(result.Rows[0][0]))
+}
+
+// Save implements Checkpointer.
+func (p *PostgresCheckpointer) Save(lsn pglogrepl.LSN) error {
+	sql := fmt.Sprintf(`INSERT INTO %s (slot_name, lsn) VALUES ($1, $2)
+		ON CONFLICT (slot_name) DO UPDATE SET lsn = EXCLUDED.lsn`, quoteIdent(p.table))
+	result := p.conn.ExecParams(context.Background(), sql, [][]byte{[]byte(p.slotName), []byte(lsn.String())}, nil, nil, nil).Read()
+	if result.Err != nil {
+		return fmt.Errorf("cdc: save checkpoint: %w", result.Err)
+	}
+	return nil
+}
+
+// Close implements Checkpointer, releasing the checkpoint store's connection.
+func (p *PostgresCheckpointer) Close(ctx context.Context) error {
+	return p.conn.Close(ctx)
+}
@@ -0,0 +1,185 @@
+package cdc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+)
+
+// OutputPlugin selects the logical decoding plugin used by the replication
+// slot.
+type OutputPlugin string
+
+const (
+	OutputPluginPgoutput OutputPlugin = "pgoutput"
+	OutputPluginWal2Json OutputPlugin = "wal2json"
+)
+
+// SnapshotMode controls whether a Subscriber backfills SnapshotTables from
+// a consistent point before (or instead of) streaming live changes.
+type SnapshotMode string
+
+const (
+	// SnapshotModeNever streams only live changes, as if no snapshot
+	// support existed. This is the default.
+	SnapshotModeNever SnapshotMode = "never"
+	// SnapshotModeInitial backfills SnapshotTables from the slot's
+	// consistent point, then streams live changes from that same point so
+	// no change is missed or duplicated.
+	SnapshotModeInitial SnapshotMode = "initial"
+	// SnapshotModeOnly backfills SnapshotTables and returns without
+	// streaming any live changes.
+	SnapshotModeOnly SnapshotMode = "only"
+)
+
+// StreamingDelivery controls when events decoded from an in-progress
+// (streamed) transaction reach the Handler.
+type StreamingDelivery string
+
+const (
+	// StreamingDeliveryEager delivers each row change to the Handler as
+	// soon as it is decoded, before the streamed transaction commits.
+	StreamingDeliveryEager StreamingDelivery = "eager"
+	// StreamingDeliveryAtCommit buffers every row change decoded from a
+	// streamed transaction and delivers them all, in order, once its
+	// StreamCommitMessage arrives - giving the Handler the same
+	// all-or-nothing view it would get for a non-streamed transaction.
+	StreamingDeliveryAtCommit StreamingDelivery = "at_commit"
+)
+
+// Config configures a Subscriber.
+type Config struct {
+	// DSN is the libpq connection string used to open the replication
+	// connection. It must include "replication=database".
+	DSN string
+
+	// SlotName is the logical replication slot to stream from. It is
+	// created with CreateReplicationSlot if it does not already exist.
+	SlotName string
+
+	// Publication is the name of the PostgreSQL publication the slot reads
+	// from. It is not created by Subscriber; it must already exist (e.g.
+	// CREATE PUBLICATION <name> FOR ALL TABLES).
+	Publication string
+
+	// OutputPlugin is the logical decoding plugin to use. Defaults to
+	// OutputPluginPgoutput.
+	//
+	// Columns of user-defined types (enums, composites, domains) are not
+	// decoded automatically: the replication protocol's TypeMessage only
+	// carries a type's name and OID, not its structure, and the
+	// replication connection has no catalog access to look that structure
+	// up. Such columns fall back to the generic text/binary decoder like
+	// any other unregistered OID, unless the caller registers a decoder
+	// for the OID itself via RelationSet.RegisterType.
+	OutputPlugin OutputPlugin
+
+	// StartLSN is the position to start streaming from, whether or not the
+	// slot already exists. If zero, streaming starts from the server's
+	// current WAL position as reported by IDENTIFY_SYSTEM. Ignored once
+	// Checkpointer has a saved position, and replaced by the slot's
+	// consistent point when an initial snapshot runs.
+	StartLSN pglogrepl.LSN
+
+	// Checkpointer durably stores the flushed LSN across restarts. If nil,
+	// the Subscriber neither loads nor saves a checkpoint and always starts
+	// from StartLSN.
+	Checkpointer Checkpointer
+
+	// WalRetain is how far, in bytes, the flushed LSN reported to the
+	// server is allowed to trail the write LSN. A non-zero value keeps
+	// recent WAL around on the server so an operator can rewind the slot
+	// and replay transactions after a consumer-side bug.
+	WalRetain uint64
+
+	// KeepaliveInterval is how often a standby status update is sent to the
+	// server in the absence of a keepalive requesting one sooner. Defaults
+	// to 10 seconds.
+	KeepaliveInterval time.Duration
+
+	// OnLag, if set, is called after every keepalive with the current
+	// replication lag in bytes: the gap between the WAL end the server
+	// reports and the last LSN this Subscriber has received (its write
+	// position, not its flushed position).
+	OnLag func(lagBytes int64)
+
+	// SnapshotMode controls whether SnapshotTables are backfilled before
+	// streaming. It only takes effect the first time SlotName is created;
+	// a Subscriber resuming from an existing slot or a saved checkpoint
+	// never re-snapshots. Defaults to SnapshotModeNever.
+	SnapshotMode SnapshotMode
+
+	// SnapshotTables lists the tables (schema-qualified, e.g. "public.orders")
+	// to back-fill when SnapshotMode is SnapshotModeInitial or
+	// SnapshotModeOnly.
+	SnapshotTables []string
+
+	// Streaming negotiates pgoutput protocol version 2 and the "streaming"
+	// plugin option, so the server forwards large in-progress transactions
+	// in chunks instead of buffering them until commit.
+	Streaming bool
+
+	// StreamingDelivery controls how chunks of a streamed transaction are
+	// handed to the Handler. Only meaningful when Streaming is true.
+	// Defaults to StreamingDeliveryEager.
+	StreamingDelivery StreamingDelivery
+
+	// Sink, if set, receives every row-change Event alongside the Handler,
+	// so a consumer of this package can forward changes to Kafka, a
+	// webhook or pg_notify without writing one. A transaction's checkpoint
+	// is only saved once Sink.Flush confirms delivery of everything
+	// published for it.
+	Sink Sink
+}
+
+func (c Config) outputPlugin() OutputPlugin {
+	if c.OutputPlugin == "" {
+		return OutputPluginPgoutput
+	}
+	return c.OutputPlugin
+}
+
+func (c Config) keepaliveInterval() time.Duration {
+	if c.KeepaliveInterval <= 0 {
+		return standbyMessageTimeout
+	}
+	return c.KeepaliveInterval
+}
+
+func (c Config) snapshotMode() SnapshotMode {
+	if c.SnapshotMode == "" {
+		return SnapshotModeNever
+	}
+	return c.SnapshotMode
+}
+
+func (c Config) streamingDelivery() StreamingDelivery {
+	if c.StreamingDelivery == "" {
+		return StreamingDeliveryEager
+	}
+	return c.StreamingDelivery
+}
+
+func (c Config) protoVersion() int {
+	if c.Streaming {
+		return 2
+	}
+	return 1
+}
+
+func (c Config) pluginArguments() []string {
+	switch c.outputPlugin() {
+	case OutputPluginWal2Json:
+		return []string{"\"pretty-print\" 'true'"}
+	default:
+		args := []string{
+			fmt.Sprintf("proto_version '%d'", c.protoVersion()),
+			"publication_names '" + c.Publication + "'",
+		}
+		if c.Streaming {
+			args = append(args, "streaming 'on'")
+		}
+		return args
+	}
+}
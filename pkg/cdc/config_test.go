@@ -0,0 +1,42 @@
+package cdc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigPluginArguments(t *testing.T) {
+	c := Config{Publication: "my_pub"}
+	got := c.pluginArguments()
+	want := []string{`proto_version '1'`, `publication_names 'my_pub'`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pluginArguments() = %#v, want %#v", got, want)
+	}
+}
+
+func TestConfigPluginArgumentsWal2Json(t *testing.T) {
+	c := Config{OutputPlugin: OutputPluginWal2Json}
+	got := c.pluginArguments()
+	want := []string{`"pretty-print" 'true'`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pluginArguments() = %#v, want %#v", got, want)
+	}
+}
+
+func TestConfigProtoVersion(t *testing.T) {
+	if got := (Config{}).protoVersion(); got != 1 {
+		t.Errorf("protoVersion() = %d, want 1", got)
+	}
+	if got := (Config{Streaming: true}).protoVersion(); got != 2 {
+		t.Errorf("protoVersion() = %d, want 2", got)
+	}
+}
+
+func TestConfigPluginArgumentsStreaming(t *testing.T) {
+	c := Config{Publication: "my_pub", Streaming: true}
+	got := c.pluginArguments()
+	want := []string{`proto_version '2'`, `publication_names 'my_pub'`, `streaming 'on'`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pluginArguments() = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package cdc
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+)
+
+// Event is the typed union of changes a Handler can receive. Concrete
+// implementations are BeginEvent, CommitEvent, InsertEvent, UpdateEvent,
+// DeleteEvent, TruncateEvent and LogicalMessageEvent.
+type Event interface {
+	isEvent()
+}
+
+// BeginEvent marks the start of a transaction. No further events for the
+// same transaction are delivered if it is later rolled back.
+type BeginEvent struct {
+	FinalLSN   pglogrepl.LSN
+	CommitTime time.Time
+	Xid        uint32
+}
+
+func (BeginEvent) isEvent() {}
+
+// CommitEvent marks the end of the transaction started by the matching
+// BeginEvent.
+type CommitEvent struct {
+	CommitLSN         pglogrepl.LSN
+	TransactionEndLSN pglogrepl.LSN
+	CommitTime        time.Time
+}
+
+func (CommitEvent) isEvent() {}
+
+// InsertEvent is emitted for a row inserted into Table. Snapshot reports
+// whether this is a synthetic insert produced while backfilling an initial
+// snapshot (see Config.SnapshotMode) rather than a row observed live on the
+// replication stream.
+type InsertEvent struct {
+	Table    string
+	New      map[string]interface{}
+	Snapshot bool
+}
+
+func (InsertEvent) isEvent() {}
+
+// UpdateEvent is emitted for a row updated in Table. Old is only populated
+// when the table's REPLICA IDENTITY exposes the previous values (FULL, or
+// the key columns when they changed).
+type UpdateEvent struct {
+	Table string
+	Old   map[string]interface{}
+	New   map[string]interface{}
+}
+
+func (UpdateEvent) isEvent() {}
+
+// DeleteEvent is emitted for a row deleted from Table. Old carries whatever
+// REPLICA IDENTITY makes available; KeyOnly reports whether that is limited
+// to the key columns (REPLICA IDENTITY DEFAULT/INDEX) rather than the full
+// row (REPLICA IDENTITY FULL).
+type DeleteEvent struct {
+	Table   string
+	Old     map[string]interface{}
+	KeyOnly bool
+}
+
+func (DeleteEvent) isEvent() {}
+
+// TruncateEvent is emitted once per table affected by a TRUNCATE statement.
+type TruncateEvent struct {
+	Table           string
+	Cascade         bool
+	RestartIdentity bool
+}
+
+func (TruncateEvent) isEvent() {}
+
+// Handler processes a decoded Event observed at the given LSN. Returning a
+// non-nil error stops the Subscriber's Run loop.
+type Handler func(ctx context.Context, event Event, lsn pglogrepl.LSN) error
@@ -0,0 +1,129 @@
+package cdc
+
+import (
+	"fmt"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgtype"
+)
+
+// RelationSet tracks the RelationMessages a replication stream has seen so
+// far and decodes TupleData against them. pgoutput only sends a
+// RelationMessage when a table's schema changes (or on first use), so the
+// set must be kept for the lifetime of the replication connection.
+type RelationSet struct {
+	connInfo  *pgtype.ConnInfo
+	relations map[uint32]*pglogrepl.RelationMessage
+}
+
+// NewRelationSet returns an empty RelationSet.
+func NewRelationSet() *RelationSet {
+	return &RelationSet{
+		connInfo:  pgtype.NewConnInfo(),
+		relations: map[uint32]*pglogrepl.RelationMessage{},
+	}
+}
+
+// Add records or replaces the relation carried by a RelationMessage.
+func (rs *RelationSet) Add(relation *pglogrepl.RelationMessage) {
+	rs.relations[relation.RelationID] = relation
+}
+
+// Get returns the relation previously recorded under relID, if any.
+func (rs *RelationSet) Get(relID uint32) (*pglogrepl.RelationMessage, bool) {
+	rel, ok := rs.relations[relID]
+	return rel, ok
+}
+
+// RegisterType registers a custom OID (enum, composite, domain, ...) so that
+// later calls to Values decode columns of that type instead of falling back
+// to the generic decoders.
+func (rs *RelationSet) RegisterType(t pgtype.DataType) {
+	rs.connInfo.RegisterDataType(t)
+}
+
+// Values decodes the columns of a tuple belonging to relID into a map keyed
+// by column name. It understands all four TupleDataColumn wire formats:
+// null, unchanged TOAST (reported as Unchanged), text and binary.
+func (rs *RelationSet) Values(relID uint32, columns []*pglogrepl.TupleDataColumn) (map[string]interface{}, error) {
+	rel, ok := rs.Get(relID)
+	if !ok {
+		return nil, fmt.Errorf("cdc: unknown relation ID %d", relID)
+	}
+	if len(columns) != len(rel.Columns) {
+		return nil, fmt.Errorf("cdc: relation %s.%s has %d columns, tuple has %d", rel.Namespace, rel.RelationName, len(rel.Columns), len(columns))
+	}
+
+	values := make(map[string]interface{}, len(columns))
+	for idx, col := range columns {
+		colName := rel.Columns[idx].Name
+		dataType := rel.Columns[idx].DataType
+
+		switch col.DataType {
+		case pglogrepl.TupleDataTypeNull:
+			values[colName] = nil
+		case pglogrepl.TupleDataTypeToast:
+			values[colName] = Unchanged
+		case pglogrepl.TupleDataTypeText:
+			val, err := rs.decodeTextColumn(col.Data, dataType)
+			if err != nil {
+				return nil, fmt.Errorf("cdc: decode %s.%s.%s: %w", rel.Namespace, rel.RelationName, colName, err)
+			}
+			values[colName] = val
+		case pglogrepl.TupleDataTypeBinary:
+			val, err := rs.decodeBinaryColumn(col.Data, dataType)
+			if err != nil {
+				return nil, fmt.Errorf("cdc: decode %s.%s.%s: %w", rel.Namespace, rel.RelationName, colName, err)
+			}
+			values[colName] = val
+		default:
+			return nil, fmt.Errorf("cdc: unknown tuple data type %q for %s.%s.%s", col.DataType, rel.Namespace, rel.RelationName, colName)
+		}
+	}
+
+	return values, nil
+}
+
+// unchangedToast is the sentinel value reported for TOASTed columns whose
+// value was not part of the update and therefore never sent on the wire.
+type unchangedToast struct{}
+
+func (unchangedToast) String() string { return "cdc.Unchanged" }
+
+// Unchanged is reported as the value of a TOASTed column that was not
+// modified by the change being decoded.
+var Unchanged = unchangedToast{}
+
+func (rs *RelationSet) decodeTextColumn(data []byte, dataType uint32) (interface{}, error) {
+	var decoder pgtype.TextDecoder
+	if dt, ok := rs.connInfo.DataTypeForOID(dataType); ok {
+		var isTextDecoder bool
+		decoder, isTextDecoder = dt.Value.(pgtype.TextDecoder)
+		if !isTextDecoder {
+			decoder = &pgtype.GenericText{}
+		}
+	} else {
+		decoder = &pgtype.GenericText{}
+	}
+	if err := decoder.DecodeText(rs.connInfo, data); err != nil {
+		return nil, err
+	}
+	return decoder.(pgtype.Value).Get(), nil
+}
+
+func (rs *RelationSet) decodeBinaryColumn(data []byte, dataType uint32) (interface{}, error) {
+	var decoder pgtype.BinaryDecoder
+	if dt, ok := rs.connInfo.DataTypeForOID(dataType); ok {
+		var isBinaryDecoder bool
+		decoder, isBinaryDecoder = dt.Value.(pgtype.BinaryDecoder)
+		if !isBinaryDecoder {
+			decoder = &pgtype.GenericBinary{}
+		}
+	} else {
+		decoder = &pgtype.GenericBinary{}
+	}
+	if err := decoder.DecodeBinary(rs.connInfo, data); err != nil {
+		return nil, err
+	}
+	return decoder.(pgtype.Value).Get(), nil
+}
@@ -0,0 +1,68 @@
+package cdc
+
+import (
+	"testing"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgtype"
+)
+
+func TestRelationSetValues(t *testing.T) {
+	rs := NewRelationSet()
+	rs.Add(&pglogrepl.RelationMessage{
+		RelationID:   1,
+		Namespace:    "public",
+		RelationName: "widgets",
+		Columns: []*pglogrepl.RelationMessageColumn{
+			{Name: "id", DataType: pgtype.Int4OID},
+			{Name: "name", DataType: pgtype.TextOID},
+			{Name: "description", DataType: pgtype.TextOID},
+			{Name: "body", DataType: pgtype.TextOID},
+		},
+	})
+
+	values, err := rs.Values(1, []*pglogrepl.TupleDataColumn{
+		{DataType: pglogrepl.TupleDataTypeText, Data: []byte("7")},
+		{DataType: pglogrepl.TupleDataTypeText, Data: []byte("widget")},
+		{DataType: pglogrepl.TupleDataTypeNull},
+		{DataType: pglogrepl.TupleDataTypeToast},
+	})
+	if err != nil {
+		t.Fatalf("Values: %v", err)
+	}
+
+	if values["id"] != int32(7) {
+		t.Errorf("id = %#v, want int32(7)", values["id"])
+	}
+	if values["name"] != "widget" {
+		t.Errorf("name = %#v, want \"widget\"", values["name"])
+	}
+	if values["description"] != nil {
+		t.Errorf("description = %#v, want nil", values["description"])
+	}
+	if values["body"] != Unchanged {
+		t.Errorf("body = %#v, want Unchanged", values["body"])
+	}
+}
+
+func TestRelationSetValuesUnknownRelation(t *testing.T) {
+	rs := NewRelationSet()
+	if _, err := rs.Values(99, nil); err == nil {
+		t.Fatal("expected error for unknown relation ID")
+	}
+}
+
+func TestRelationSetValuesColumnCountMismatch(t *testing.T) {
+	rs := NewRelationSet()
+	rs.Add(&pglogrepl.RelationMessage{
+		RelationID:   1,
+		Namespace:    "public",
+		RelationName: "widgets",
+		Columns: []*pglogrepl.RelationMessageColumn{
+			{Name: "id", DataType: pgtype.Int4OID},
+		},
+	})
+	if _, err := rs.Values(1, nil); err == nil {
+		t.Fatal("expected error for column count mismatch")
+	}
+}
@@ -0,0 +1,54 @@
+package cdc
+
+import (
+	"context"
+
+	"github.com/jackc/pglogrepl"
+)
+
+// Sink publishes decoded Events to an external system - a message queue, a
+// webhook, a LISTEN/NOTIFY channel - so that a consumer of this package does
+// not have to write a Handler just to forward changes elsewhere. It mirrors
+// Handler's signature so a Subscriber can treat "call the handler" and
+// "publish to the sink" identically.
+type Sink interface {
+	// Publish sends event, observed at lsn, to the sink. Implementations
+	// may buffer internally rather than wait for the destination to
+	// acknowledge receipt; use Flush to wait for that.
+	Publish(ctx context.Context, event Event, lsn pglogrepl.LSN) error
+
+	// Flush blocks until every Event previously passed to Publish has been
+	// acknowledged by the destination. The Subscriber calls Flush once per
+	// transaction, after delivering its CommitEvent, and only advances the
+	// checkpointed LSN once Flush returns nil - so a sink that is behind or
+	// unreachable keeps the slot retaining that transaction's WAL instead
+	// of it being silently lost.
+	Flush(ctx context.Context) error
+
+	// Close releases any resources held by the sink (connections,
+	// producers, ...).
+	Close() error
+}
+
+// eventKind names the concrete type behind an Event, for sinks that encode
+// it alongside the event itself (e.g. as a JSON envelope field).
+func eventKind(event Event) string {
+	switch event.(type) {
+	case BeginEvent:
+		return "begin"
+	case CommitEvent:
+		return "commit"
+	case InsertEvent:
+		return "insert"
+	case UpdateEvent:
+		return "update"
+	case DeleteEvent:
+		return "delete"
+	case TruncateEvent:
+		return "truncate"
+	case LogicalMessageEvent:
+		return "logical_message"
+	default:
+		return "unknown"
+	}
+}
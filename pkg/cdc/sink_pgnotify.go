@@ -0,0 +1,71 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pglogrepl"
+)
+
+// PgNotifySinkConfig configures a PgNotifySink.
+type PgNotifySinkConfig struct {
+	// DSN is the libpq connection string used to open the connection
+	// NOTIFY is issued on. It must be a separate, non-replication
+	// connection: a replication-mode connection only accepts the
+	// replication protocol's own command subset.
+	DSN string
+
+	// Channel is the channel name passed to pg_notify.
+	Channel string
+}
+
+// PgNotifySink publishes each Event as a JSON payload via
+// pg_notify(channel, payload), for consumers using LISTEN/NOTIFY (e.g.
+// lib/pq's Listener) instead of a message queue.
+type PgNotifySink struct {
+	config PgNotifySinkConfig
+	conn   *pgconn.PgConn
+}
+
+// NewPgNotifySink opens the connection PgNotifySink issues NOTIFY on.
+func NewPgNotifySink(ctx context.Context, config PgNotifySinkConfig) (*PgNotifySink, error) {
+	conn, err := pgconn.Connect(ctx, config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("cdc: connect pg_notify sink: %w", err)
+	}
+	return &PgNotifySink{config: config, conn: conn}, nil
+}
+
+type pgNotifyPayload struct {
+	Event string `json:"event"`
+	LSN   string `json:"lsn"`
+	Data  Event  `json:"data"`
+}
+
+// Publish implements Sink.
+func (s *PgNotifySink) Publish(ctx context.Context, event Event, lsn pglogrepl.LSN) error {
+	payload, err := json.Marshal(pgNotifyPayload{
+		Event: eventKind(event),
+		LSN:   lsn.String(),
+		Data:  event,
+	})
+	if err != nil {
+		return fmt.Errorf("cdc: marshal pg_notify payload: %w", err)
+	}
+
+	result := s.conn.ExecParams(ctx, "SELECT pg_notify($1, $2)",
+		[][]byte{[]byte(s.config.Channel), payload}, nil, nil, nil).Read()
+	if result.Err != nil {
+		return fmt.Errorf("cdc: pg_notify: %w", result.Err)
+	}
+	return nil
+}
+
+// Flush is a no-op: Publish's ExecParams call already waits for the server
+// to process the NOTIFY before returning.
+func (s *PgNotifySink) Flush(ctx context.Context) error { return nil }
+
+// Close closes the NOTIFY connection.
+func (s *PgNotifySink) Close() error { return s.conn.Close(context.Background()) }
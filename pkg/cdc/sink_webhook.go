@@ -0,0 +1,136 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+)
+
+// WebhookSinkConfig configures a WebhookSink.
+type WebhookSinkConfig struct {
+	// URL is the endpoint every Event is POSTed to as JSON.
+	URL string
+
+	// Secret, if set, HMAC-SHA256-signs the request body; the signature is
+	// sent in the X-CDC-Signature header as "sha256=<hex>", the convention
+	// used by GitHub and Stripe webhooks.
+	Secret string
+
+	// MaxRetries is how many additional POST attempts are made after an
+	// initial failure before Publish gives up. Defaults to 3.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry, doubled after each
+	// further attempt. Defaults to 500ms.
+	RetryBackoff time.Duration
+
+	// Client sends the HTTP requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func (c WebhookSinkConfig) maxRetries() int {
+	if c.MaxRetries <= 0 {
+		return 3
+	}
+	return c.MaxRetries
+}
+
+func (c WebhookSinkConfig) retryBackoff() time.Duration {
+	if c.RetryBackoff <= 0 {
+		return 500 * time.Millisecond
+	}
+	return c.RetryBackoff
+}
+
+// WebhookSink publishes each Event as a signed JSON POST, retrying with
+// exponential backoff on failure.
+type WebhookSink struct {
+	config WebhookSinkConfig
+	client *http.Client
+}
+
+// NewWebhookSink returns a WebhookSink posting to config.URL.
+func NewWebhookSink(config WebhookSinkConfig) *WebhookSink {
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookSink{config: config, client: client}
+}
+
+type webhookPayload struct {
+	Event string `json:"event"`
+	LSN   string `json:"lsn"`
+	Data  Event  `json:"data"`
+}
+
+// Publish implements Sink.
+func (s *WebhookSink) Publish(ctx context.Context, event Event, lsn pglogrepl.LSN) error {
+	body, err := json.Marshal(webhookPayload{
+		Event: eventKind(event),
+		LSN:   lsn.String(),
+		Data:  event,
+	})
+	if err != nil {
+		return fmt.Errorf("cdc: marshal webhook payload: %w", err)
+	}
+
+	backoff := s.config.retryBackoff()
+	var lastErr error
+	for attempt := 0; attempt <= s.config.maxRetries(); attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		if lastErr = s.post(ctx, body); lastErr == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("cdc: webhook publish failed after %d attempts: %w", s.config.maxRetries()+1, lastErr)
+}
+
+func (s *WebhookSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.config.Secret))
+		mac.Write(body)
+		req.Header.Set("X-CDC-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Flush is a no-op: Publish already waits for the webhook's response (or
+// exhausts its retries) before returning.
+func (s *WebhookSink) Flush(ctx context.Context) error { return nil }
+
+// Close is a no-op; WebhookSink holds no resources beyond its http.Client.
+func (s *WebhookSink) Close() error { return nil }
@@ -0,0 +1,190 @@
+package cdc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// snapshotColumn is a single column of a table being backfilled, in the
+// same order COPY ... TO STDOUT emits them.
+type snapshotColumn struct {
+	name string
+	oid  uint32
+}
+
+// runSnapshot backfills every table in s.config.SnapshotTables against the
+// exported snapshot snapshotName, emitting a synthetic InsertEvent per row.
+// It must run before the Subscriber's replication connection issues
+// START_REPLICATION (or any other command): that is what keeps the exported
+// snapshot valid for the duration of this function.
+func (s *Subscriber) runSnapshot(ctx context.Context, snapshotName string) (err error) {
+	conn, err := pgconn.Connect(ctx, s.config.DSN)
+	if err != nil {
+		return fmt.Errorf("cdc: connect snapshot: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err = conn.Exec(ctx, "BEGIN ISOLATION LEVEL REPEATABLE READ, READ ONLY").ReadAll(); err != nil {
+		return fmt.Errorf("cdc: begin snapshot transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			conn.Exec(ctx, "ROLLBACK").ReadAll()
+			return
+		}
+		if _, cerr := conn.Exec(ctx, "COMMIT").ReadAll(); cerr != nil {
+			err = fmt.Errorf("cdc: commit snapshot transaction: %w", cerr)
+		}
+	}()
+
+	quotedSnapshot := "'" + strings.ReplaceAll(snapshotName, "'", "''") + "'"
+	if _, err = conn.Exec(ctx, "SET TRANSACTION SNAPSHOT "+quotedSnapshot).ReadAll(); err != nil {
+		return fmt.Errorf("cdc: set transaction snapshot: %w", err)
+	}
+
+	for _, table := range s.config.SnapshotTables {
+		if err = s.copySnapshotTable(ctx, conn, table); err != nil {
+			return fmt.Errorf("cdc: snapshot table %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Subscriber) copySnapshotTable(ctx context.Context, conn *pgconn.PgConn, table string) error {
+	columns, err := snapshotTableColumns(ctx, conn, table)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	copyDone := make(chan error, 1)
+	go func() {
+		_, copyErr := conn.CopyTo(ctx, pw, fmt.Sprintf("COPY %s TO STDOUT", quoteIdent(table)))
+		pw.CloseWithError(copyErr)
+		copyDone <- copyErr
+	}()
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		row, err := decodeSnapshotRow(s.relations, columns, scanner.Text())
+		if err != nil {
+			return err
+		}
+		if err := s.publish(ctx, InsertEvent{Table: table, New: row, Snapshot: true}, 0); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cdc: read copy stream for %s: %w", table, err)
+	}
+
+	if err := <-copyDone; err != nil {
+		return fmt.Errorf("cdc: copy %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// snapshotTableColumns returns table's non-dropped columns, in attnum
+// order, matching the order COPY ... TO STDOUT writes them in.
+func snapshotTableColumns(ctx context.Context, conn *pgconn.PgConn, table string) ([]snapshotColumn, error) {
+	const sql = `SELECT a.attname, a.atttypid FROM pg_catalog.pg_attribute a ` +
+		`WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum`
+	result := conn.ExecParams(ctx, sql, [][]byte{[]byte(table)}, nil, nil, nil).Read()
+	if result.Err != nil {
+		return nil, fmt.Errorf("cdc: list columns for %s: %w", table, result.Err)
+	}
+	if len(result.Rows) == 0 {
+		return nil, fmt.Errorf("cdc: table %s has no columns (does it exist?)", table)
+	}
+
+	columns := make([]snapshotColumn, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		oid, err := strconv.ParseUint(string(row[1]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("cdc: parse type oid for %s: %w", table, err)
+		}
+		columns = append(columns, snapshotColumn{name: string(row[0]), oid: uint32(oid)})
+	}
+	return columns, nil
+}
+
+// quoteIdent quotes ident as a Postgres identifier, so it can be safely
+// interpolated into statements (such as COPY) that do not accept bind
+// parameters for identifiers. Each dot-separated part is quoted separately
+// so schema-qualified names (e.g. "public.foo") stay valid after quoting.
+func quoteIdent(ident string) string {
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// decodeSnapshotRow parses one COPY TO STDOUT text-format line into a
+// column-name-keyed map, decoding each field through the same text decoders
+// RelationSet uses for live tuples.
+func decodeSnapshotRow(relations *RelationSet, columns []snapshotColumn, line string) (map[string]interface{}, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != len(columns) {
+		return nil, fmt.Errorf("cdc: expected %d columns in copy row, got %d", len(columns), len(fields))
+	}
+
+	values := make(map[string]interface{}, len(columns))
+	for i, field := range fields {
+		if field == `\N` {
+			values[columns[i].name] = nil
+			continue
+		}
+		val, err := relations.decodeTextColumn([]byte(unescapeCopyText(field)), columns[i].oid)
+		if err != nil {
+			return nil, fmt.Errorf("cdc: decode column %s: %w", columns[i].name, err)
+		}
+		values[columns[i].name] = val
+	}
+	return values, nil
+}
+
+// unescapeCopyText reverses the backslash escaping COPY ... TO STDOUT
+// applies in its default text format.
+func unescapeCopyText(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
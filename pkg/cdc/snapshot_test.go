@@ -0,0 +1,44 @@
+package cdc
+
+import "testing"
+
+func TestUnescapeCopyText(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{`a\tb`, "a\tb"},
+		{`a\nb`, "a\nb"},
+		{`a\rb`, "a\rb"},
+		{`a\\b`, `a\b`},
+		{`a\bb`, "a\bb"},
+		{`a\fb`, "a\fb"},
+		{`a\vb`, "a\vb"},
+		{`a\qb`, "aqb"},
+		{`trailing\`, "trailing\\"},
+	}
+
+	for _, c := range cases {
+		if got := unescapeCopyText(c.in); got != c.want {
+			t.Errorf("unescapeCopyText(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"widgets", `"widgets"`},
+		{"public.widgets", `"public"."widgets"`},
+		{`weird"name`, `"weird""name"`},
+	}
+
+	for _, c := range cases {
+		if got := quoteIdent(c.in); got != c.want {
+			t.Errorf("quoteIdent(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
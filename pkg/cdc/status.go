@@ -0,0 +1,30 @@
+package cdc
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pglogrepl"
+)
+
+// positions is a snapshot of the three LSNs the replication protocol wants
+// reported back to the server: how far the client has written, flushed and
+// applied the stream.
+type positions struct {
+	write pglogrepl.LSN
+	flush pglogrepl.LSN
+	apply pglogrepl.LSN
+}
+
+// sendStandbyStatusUpdate reports p to the server. pgconn.PgConn is
+// documented as not safe for concurrent use, so this is always called from
+// streamLoop's own goroutine, in between ReceiveMessage calls on the same
+// conn, rather than handed off to a separate sender goroutine that could
+// race it.
+func sendStandbyStatusUpdate(ctx context.Context, conn *pgconn.PgConn, p positions) error {
+	return pglogrepl.SendStandbyStatusUpdate(ctx, conn, pglogrepl.StandbyStatusUpdate{
+		WALWritePosition: p.write,
+		WALFlushPosition: p.flush,
+		WALApplyPosition: p.apply,
+	})
+}
@@ -0,0 +1,62 @@
+package cdc
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseStreamCommitMessage(t *testing.T) {
+	data := make([]byte, 29)
+	binary.BigEndian.PutUint32(data, 42)
+	data[4] = 1
+	binary.BigEndian.PutUint64(data[5:], 100)
+	binary.BigEndian.PutUint64(data[13:], 200)
+	binary.BigEndian.PutUint64(data[21:], 0)
+
+	msg, err := parseStreamCommitMessage(data)
+	if err != nil {
+		t.Fatalf("parseStreamCommitMessage: %v", err)
+	}
+	if msg.Xid != 42 || msg.Flags != 1 || msg.CommitLSN != 100 || msg.TransactionEndLSN != 200 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if !msg.CommitTime.Equal(postgresEpoch) {
+		t.Fatalf("CommitTime = %v, want %v", msg.CommitTime, postgresEpoch)
+	}
+}
+
+func TestParseStreamCommitMessageTooShort(t *testing.T) {
+	if _, err := parseStreamCommitMessage(make([]byte, 10)); err == nil {
+		t.Fatal("expected error for short StreamCommitMessage")
+	}
+}
+
+func TestParseLogicalDecodingMessage(t *testing.T) {
+	data := []byte{1}
+	lsn := make([]byte, 8)
+	binary.BigEndian.PutUint64(lsn, 500)
+	data = append(data, lsn...)
+	data = append(data, []byte("prefix\x00")...)
+	contentLen := make([]byte, 4)
+	binary.BigEndian.PutUint32(contentLen, 5)
+	data = append(data, contentLen...)
+	data = append(data, []byte("hello")...)
+
+	msg, err := parseLogicalDecodingMessage(data)
+	if err != nil {
+		t.Fatalf("parseLogicalDecodingMessage: %v", err)
+	}
+	if !msg.Transactional || msg.LSN != 500 || msg.Prefix != "prefix" || string(msg.Content) != "hello" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+func TestParseLogicalDecodingMessageMissingNul(t *testing.T) {
+	data := make([]byte, 14)
+	for i := 9; i < len(data); i++ {
+		data[i] = 'x'
+	}
+	if _, err := parseLogicalDecodingMessage(data); err == nil {
+		t.Fatal("expected error for missing prefix terminator")
+	}
+}
@@ -0,0 +1,540 @@
+// Package cdc implements a reusable PostgreSQL logical replication (CDC)
+// client on top of pglogrepl/pgoutput. It decodes the wire protocol into a
+// typed Event and hands each one to a user-supplied Handler, so that
+// embedding this in a service does not require reimplementing relation
+// tracking, tuple decoding or the replication receive loop.
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgproto3/v2"
+)
+
+// standbyMessageTimeout is how often a standby status update is sent to the
+// server in the absence of a keepalive requesting one sooner.
+const standbyMessageTimeout = 10 * time.Second
+
+// Subscriber streams logical replication changes from a single PostgreSQL
+// replication slot and delivers them to a Handler.
+type Subscriber struct {
+	config  Config
+	handler Handler
+
+	relations *RelationSet
+	walRetain pglogrepl.LSN
+
+	pos positions
+
+	// inTxn and pendingCommitLSN track the transaction currently being
+	// streamed, between a BeginMessage and its matching CommitMessage.
+	inTxn            bool
+	pendingCommitLSN pglogrepl.LSN
+
+	// streamXid is the transaction ID of the in-progress (protocol v2
+	// "streaming") transaction currently being received, or zero between
+	// transactions. streamBuffer holds its events when
+	// Config.StreamingDelivery is StreamingDeliveryAtCommit.
+	streamXid    uint32
+	streamBuffer *StreamingTxnBuffer
+
+	// lastMessageAt is the local time the most recently received keepalive
+	// or XLogData message was handled, used to schedule the next standby
+	// status update. It deliberately stays in the local clock domain:
+	// nextStandbyDeadline's result is compared against time.Now() and fed
+	// into context.WithDeadline, both of which resolve against the local
+	// clock, so mixing in the server's reported clock would make the
+	// deadline wrong by however much the two clocks have drifted apart.
+	lastMessageAt time.Time
+}
+
+// NewSubscriber returns a Subscriber that will call handler for every
+// decoded Event once Run is started.
+func NewSubscriber(config Config, handler Handler) *Subscriber {
+	return &Subscriber{
+		config:       config,
+		handler:      handler,
+		relations:    NewRelationSet(),
+		walRetain:    pglogrepl.LSN(config.WalRetain),
+		streamBuffer: NewStreamingTxnBuffer(),
+	}
+}
+
+// Run connects to PostgreSQL, ensures the replication slot exists, and
+// streams changes until ctx is cancelled or an unrecoverable error occurs.
+func (s *Subscriber) Run(ctx context.Context) error {
+	conn, err := pgconn.Connect(ctx, s.config.DSN)
+	if err != nil {
+		return fmt.Errorf("cdc: connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	if s.config.Sink != nil {
+		defer s.config.Sink.Close()
+	}
+	if s.config.Checkpointer != nil {
+		defer s.config.Checkpointer.Close(ctx)
+	}
+
+	sysident, err := pglogrepl.IdentifySystem(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("cdc: identify system: %w", err)
+	}
+
+	created, slotResult, err := s.ensureSlot(ctx, conn, sysident)
+	if err != nil {
+		return err
+	}
+
+	startLSN := s.config.StartLSN
+	if s.config.Checkpointer != nil {
+		saved, err := s.config.Checkpointer.Load()
+		if err != nil {
+			return fmt.Errorf("cdc: load checkpoint: %w", err)
+		}
+		if saved != 0 {
+			// Resuming from a checkpoint means we've already streamed past
+			// whatever the slot's (possibly brand new) consistent point is;
+			// a fresh snapshot would just re-insert rows the handler has
+			// already seen.
+			startLSN = saved
+			created = false
+		}
+	}
+
+	if created && s.config.snapshotMode() != SnapshotModeNever {
+		consistentLSN, err := pglogrepl.ParseLSN(slotResult.ConsistentPoint)
+		if err != nil {
+			return fmt.Errorf("cdc: parse consistent point %q: %w", slotResult.ConsistentPoint, err)
+		}
+		startLSN = consistentLSN
+
+		if err := s.runSnapshot(ctx, slotResult.SnapshotName); err != nil {
+			return fmt.Errorf("cdc: initial snapshot: %w", err)
+		}
+
+		if s.config.snapshotMode() == SnapshotModeOnly {
+			return nil
+		}
+	}
+
+	if startLSN == 0 {
+		startLSN = sysident.XLogPos
+	}
+
+	err = pglogrepl.StartReplication(ctx, conn, s.config.SlotName, startLSN, pglogrepl.StartReplicationOptions{
+		PluginArgs: s.config.pluginArguments(),
+	})
+	if err != nil {
+		return fmt.Errorf("cdc: start replication: %w", err)
+	}
+
+	s.pos = positions{write: startLSN, flush: startLSN, apply: startLSN}
+
+	return s.streamLoop(ctx, conn)
+}
+
+// ensureSlot creates the configured replication slot if it does not already
+// exist. created reports whether this call created it; result is only
+// populated in that case, and carries the consistent point/snapshot name
+// when SnapshotMode requested one.
+func (s *Subscriber) ensureSlot(ctx context.Context, conn *pgconn.PgConn, sysident pglogrepl.IdentifySystemResult) (created bool, result pglogrepl.CreateReplicationSlotResult, err error) {
+	const sql = `SELECT * FROM pg_replication_slots WHERE slot_name = $1`
+	res := conn.ExecParams(ctx, sql, [][]byte{[]byte(s.config.SlotName)}, nil, nil, nil).Read()
+	if res.Err != nil {
+		return false, result, fmt.Errorf("cdc: query replication slots: %w", res.Err)
+	}
+	if len(res.Rows) != 0 {
+		return false, result, nil
+	}
+
+	var snapshotAction string
+	if s.config.snapshotMode() != SnapshotModeNever {
+		snapshotAction = "EXPORT_SNAPSHOT"
+	}
+
+	result, err = pglogrepl.CreateReplicationSlot(ctx, conn, s.config.SlotName, string(s.config.outputPlugin()), pglogrepl.CreateReplicationSlotOptions{
+		Temporary:      false,
+		SnapshotAction: snapshotAction,
+	})
+	if err != nil {
+		return false, result, fmt.Errorf("cdc: create replication slot: %w", err)
+	}
+
+	return true, result, nil
+}
+
+func (s *Subscriber) streamLoop(ctx context.Context, conn *pgconn.PgConn) error {
+	nextStandbyMessageDeadline := s.nextStandbyDeadline()
+
+	for {
+		if time.Now().After(nextStandbyMessageDeadline) {
+			if err := sendStandbyStatusUpdate(ctx, conn, s.pos); err != nil {
+				return fmt.Errorf("cdc: send standby status update: %w", err)
+			}
+			nextStandbyMessageDeadline = s.nextStandbyDeadline()
+		}
+
+		recvCtx, cancel := context.WithDeadline(ctx, nextStandbyMessageDeadline)
+		rawMsg, err := conn.ReceiveMessage(recvCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("cdc: receive message: %w", err)
+		}
+
+		if errMsg, ok := rawMsg.(*pgproto3.ErrorResponse); ok {
+			return fmt.Errorf("cdc: received Postgres WAL error: %+v", errMsg)
+		}
+
+		copyData, ok := rawMsg.(*pgproto3.CopyData)
+		if !ok {
+			continue
+		}
+
+		switch copyData.Data[0] {
+		case pglogrepl.PrimaryKeepaliveMessageByteID:
+			pkm, err := pglogrepl.ParsePrimaryKeepaliveMessage(copyData.Data[1:])
+			if err != nil {
+				return fmt.Errorf("cdc: parse primary keepalive message: %w", err)
+			}
+			s.lastMessageAt = time.Now()
+
+			if s.config.OnLag != nil {
+				s.config.OnLag(int64(pkm.ServerWALEnd) - int64(s.pos.write))
+			}
+
+			if pkm.ReplyRequested {
+				// With no transaction currently in flight there is nothing
+				// more recent than the server's own WAL end to report, so
+				// use it directly instead of waiting for our own write
+				// position to catch up - this is what keeps lag from
+				// accumulating on tables that rarely change.
+				if !s.inTxn {
+					s.pos.write = pkm.ServerWALEnd
+				}
+				if err := sendStandbyStatusUpdate(ctx, conn, s.pos); err != nil {
+					return fmt.Errorf("cdc: send standby status update: %w", err)
+				}
+				nextStandbyMessageDeadline = s.nextStandbyDeadline()
+			}
+
+		case pglogrepl.XLogDataByteID:
+			xld, err := pglogrepl.ParseXLogData(copyData.Data[1:])
+			if err != nil {
+				return fmt.Errorf("cdc: parse XLogData: %w", err)
+			}
+			s.lastMessageAt = time.Now()
+
+			if err := s.handleWALData(ctx, xld); err != nil {
+				return err
+			}
+
+			s.pos.write = xld.WALStart + pglogrepl.LSN(len(xld.WALData))
+		}
+	}
+}
+
+// nextStandbyDeadline schedules the next standby status update
+// keepaliveInterval after the last message was received, entirely in the
+// local clock domain - it is compared against time.Now() and used as a
+// context.WithDeadline, both local, so anchoring it to the server's
+// reported clock would make the deadline wrong by however much the two
+// clocks have skewed.
+func (s *Subscriber) nextStandbyDeadline() time.Time {
+	base := s.lastMessageAt
+	if base.IsZero() {
+		base = time.Now()
+	}
+	return base.Add(s.config.keepaliveInterval())
+}
+
+// handleWALData decodes a single logical decoding message and, where it
+// produces a user-visible change, invokes the handler.
+func (s *Subscriber) handleWALData(ctx context.Context, xld pglogrepl.XLogData) error {
+	if len(xld.WALData) == 0 {
+		return fmt.Errorf("cdc: empty XLogData")
+	}
+
+	// Protocol version 2 streaming messages are not in pglogrepl's message
+	// set, so they are decoded here and never reach pglogrepl.Parse.
+	switch xld.WALData[0] {
+	case streamStartByteID:
+		return s.handleStreamStart(xld.WALData[1:])
+	case streamStopByteID:
+		// StreamStop only pauses the current chunk of streamXid's
+		// transaction; the protocol allows other, already-complete
+		// transactions to be interleaved with its remaining chunks before
+		// the stream resumes. Clearing streamXid here means those
+		// interleaved transactions' events go through deliverOrBuffer as
+		// ordinary (non-streamed) events instead of being buffered under
+		// the paused transaction's xid.
+		s.streamXid = 0
+		return nil
+	case streamCommitByteID:
+		return s.handleStreamCommit(ctx, xld.WALData[1:])
+	case streamAbortByteID:
+		return s.handleStreamAbort(xld.WALData[1:])
+	case logicalMessageByteID:
+		event, err := parseLogicalDecodingMessage(xld.WALData[1:])
+		if err != nil {
+			return err
+		}
+		return s.deliverOrBuffer(ctx, event, xld.WALStart)
+	}
+
+	logicalMsg, err := pglogrepl.Parse(xld.WALData)
+	if err != nil {
+		return fmt.Errorf("cdc: parse logical replication message: %w", err)
+	}
+
+	switch msg := logicalMsg.(type) {
+	case *pglogrepl.RelationMessage:
+		s.relations.Add(msg)
+		return nil
+
+	case *pglogrepl.BeginMessage:
+		s.inTxn = true
+		s.pendingCommitLSN = msg.FinalLSN
+		return s.handler(ctx, BeginEvent{
+			FinalLSN:   msg.FinalLSN,
+			CommitTime: msg.CommitTime,
+			Xid:        msg.Xid,
+		}, xld.WALStart)
+
+	case *pglogrepl.CommitMessage:
+		if s.pendingCommitLSN != 0 && msg.CommitLSN != s.pendingCommitLSN {
+			return fmt.Errorf("cdc: commit LSN mismatch: begin announced final LSN %s, commit reports %s", s.pendingCommitLSN, msg.CommitLSN)
+		}
+		s.inTxn = false
+		s.pendingCommitLSN = 0
+
+		if err := s.handler(ctx, CommitEvent{
+			CommitLSN:         msg.CommitLSN,
+			TransactionEndLSN: msg.TransactionEndLSN,
+			CommitTime:        msg.CommitTime,
+		}, xld.WALStart); err != nil {
+			return err
+		}
+
+		if err := s.flushSink(ctx); err != nil {
+			return err
+		}
+
+		return s.advanceFlush(msg.TransactionEndLSN)
+
+	case *pglogrepl.InsertMessage:
+		rel, ok := s.relations.Get(msg.RelationID)
+		if !ok {
+			return fmt.Errorf("cdc: unknown relation ID %d", msg.RelationID)
+		}
+		values, err := s.relations.Values(msg.RelationID, msg.Tuple.Columns)
+		if err != nil {
+			return err
+		}
+		return s.deliverOrBuffer(ctx, InsertEvent{
+			Table: rel.Namespace + "." + rel.RelationName,
+			New:   values,
+		}, xld.WALStart)
+
+	case *pglogrepl.UpdateMessage:
+		rel, ok := s.relations.Get(msg.RelationID)
+		if !ok {
+			return fmt.Errorf("cdc: unknown relation ID %d", msg.RelationID)
+		}
+		var old map[string]interface{}
+		if msg.OldTuple != nil {
+			old, err = s.relations.Values(msg.RelationID, msg.OldTuple.Columns)
+			if err != nil {
+				return err
+			}
+		}
+		newValues, err := s.relations.Values(msg.RelationID, msg.NewTuple.Columns)
+		if err != nil {
+			return err
+		}
+		return s.deliverOrBuffer(ctx, UpdateEvent{
+			Table: rel.Namespace + "." + rel.RelationName,
+			Old:   old,
+			New:   newValues,
+		}, xld.WALStart)
+
+	case *pglogrepl.DeleteMessage:
+		rel, ok := s.relations.Get(msg.RelationID)
+		if !ok {
+			return fmt.Errorf("cdc: unknown relation ID %d", msg.RelationID)
+		}
+		old, err := s.relations.Values(msg.RelationID, msg.OldTuple.Columns)
+		if err != nil {
+			return err
+		}
+		return s.deliverOrBuffer(ctx, DeleteEvent{
+			Table:   rel.Namespace + "." + rel.RelationName,
+			Old:     old,
+			KeyOnly: msg.OldTupleType == pglogrepl.DeleteMessageTupleTypeKey,
+		}, xld.WALStart)
+
+	case *pglogrepl.TruncateMessage:
+		for _, relID := range msg.RelationIDs {
+			rel, ok := s.relations.Get(relID)
+			if !ok {
+				return fmt.Errorf("cdc: unknown relation ID %d", relID)
+			}
+			err := s.deliverOrBuffer(ctx, TruncateEvent{
+				Table:           rel.Namespace + "." + rel.RelationName,
+				Cascade:         msg.Option&pglogrepl.TruncateOptionCascade != 0,
+				RestartIdentity: msg.Option&pglogrepl.TruncateOptionRestartIdentity != 0,
+			}, xld.WALStart)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *pglogrepl.TypeMessage:
+		// TypeMessage only carries the type's name and OID, not its
+		// structure (enum labels, composite attributes, ...), and this
+		// connection is replication-only so there is no catalog to query
+		// for that either. Without it there is no codec to register that
+		// would decode differently from decodeTextColumn/decodeBinaryColumn's
+		// existing unregistered-OID fallback, so there is nothing useful to
+		// do here; RelationSet.RegisterType remains available directly for
+		// callers that want to supply a real decoder for a known OID.
+		return nil
+
+	case *pglogrepl.OriginMessage:
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// advanceFlush records flush/apply positions for a transaction that ended at
+// transactionEndLSN, up to WalRetain bytes behind, and checkpoints them. It
+// is called once a transaction's events have all been handed to the Handler,
+// whether that happened via a single CommitMessage or a streamed
+// StreamCommitMessage.
+func (s *Subscriber) advanceFlush(transactionEndLSN pglogrepl.LSN) error {
+	flush := transactionEndLSN
+	if flush > s.walRetain {
+		flush -= s.walRetain
+	} else {
+		flush = 0
+	}
+	s.pos.flush = flush
+	s.pos.apply = flush
+
+	if s.config.Checkpointer != nil {
+		if err := s.config.Checkpointer.Save(flush); err != nil {
+			return fmt.Errorf("cdc: save checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// flushSink waits for Config.Sink, if configured, to acknowledge delivery of
+// everything published so far. It is called after a transaction's CommitEvent
+// and before advanceFlush, so the checkpointed LSN never moves past a
+// transaction the sink hasn't actually delivered.
+func (s *Subscriber) flushSink(ctx context.Context) error {
+	if s.config.Sink == nil {
+		return nil
+	}
+	if err := s.config.Sink.Flush(ctx); err != nil {
+		return fmt.Errorf("cdc: flush sink: %w", err)
+	}
+	return nil
+}
+
+// deliverOrBuffer hands event to the Handler, unless it was decoded from a
+// streamed (protocol v2) transaction and Config.StreamingDelivery is
+// StreamingDeliveryAtCommit, in which case it is held in streamBuffer until
+// that transaction's StreamCommitMessage arrives.
+func (s *Subscriber) deliverOrBuffer(ctx context.Context, event Event, lsn pglogrepl.LSN) error {
+	if s.streamXid != 0 && s.config.streamingDelivery() == StreamingDeliveryAtCommit {
+		s.streamBuffer.Append(s.streamXid, event, lsn)
+		return nil
+	}
+	return s.publish(ctx, event, lsn)
+}
+
+// publish forwards event to Config.Sink, if one is configured, before
+// passing it to the Handler.
+func (s *Subscriber) publish(ctx context.Context, event Event, lsn pglogrepl.LSN) error {
+	if s.config.Sink != nil {
+		if err := s.config.Sink.Publish(ctx, event, lsn); err != nil {
+			return fmt.Errorf("cdc: publish to sink: %w", err)
+		}
+	}
+	return s.handler(ctx, event, lsn)
+}
+
+// handleStreamStart records the transaction a following chunk of streamed
+// events belongs to.
+func (s *Subscriber) handleStreamStart(data []byte) error {
+	msg, err := parseStreamStartMessage(data)
+	if err != nil {
+		return err
+	}
+	s.streamXid = msg.Xid
+	return nil
+}
+
+// handleStreamCommit delivers (or releases the buffered events of) a
+// streamed transaction once it commits, then advances the flush position.
+func (s *Subscriber) handleStreamCommit(ctx context.Context, data []byte) error {
+	msg, err := parseStreamCommitMessage(data)
+	if err != nil {
+		return err
+	}
+	s.streamXid = 0
+
+	if s.config.streamingDelivery() == StreamingDeliveryAtCommit {
+		for _, buffered := range s.streamBuffer.Take(msg.Xid) {
+			if err := s.publish(ctx, buffered.event, buffered.lsn); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.handler(ctx, CommitEvent{
+		CommitLSN:         msg.CommitLSN,
+		TransactionEndLSN: msg.TransactionEndLSN,
+		CommitTime:        msg.CommitTime,
+	}, msg.TransactionEndLSN); err != nil {
+		return err
+	}
+
+	if err := s.flushSink(ctx); err != nil {
+		return err
+	}
+
+	return s.advanceFlush(msg.TransactionEndLSN)
+}
+
+// handleStreamAbort discards a streamed transaction's buffered events. A
+// subtransaction-only abort (SubXid != Xid) is left untouched rather than
+// risk discarding unrelated rows still pending for the same top-level
+// transaction; StreamingTxnBuffer has no finer granularity than xid.
+func (s *Subscriber) handleStreamAbort(data []byte) error {
+	msg, err := parseStreamAbortMessage(data)
+	if err != nil {
+		return err
+	}
+	s.streamXid = 0
+	if msg.SubXid == msg.Xid {
+		s.streamBuffer.Discard(msg.Xid)
+	}
+	return nil
+}
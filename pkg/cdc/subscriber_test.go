@@ -0,0 +1,60 @@
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/jackc/pglogrepl"
+)
+
+// TestHandleWALDataStreamStopClearsXid drives handleWALData through a
+// streamed transaction's Start and Stop, then hands an ordinary
+// (non-streamed) transaction's InsertEvent to deliverOrBuffer, the way
+// pgoutput v2 legally interleaves them. It
+// guards against streamXid surviving StreamStop and causing the interleaved
+// transaction's events to be misattributed to the paused stream instead of
+// delivered immediately.
+func TestHandleWALDataStreamStopClearsXid(t *testing.T) {
+	var delivered []Event
+	s := NewSubscriber(Config{StreamingDelivery: StreamingDeliveryAtCommit}, func(ctx context.Context, event Event, lsn pglogrepl.LSN) error {
+		delivered = append(delivered, event)
+		return nil
+	})
+	s.relations.Add(&pglogrepl.RelationMessage{
+		RelationID:   1,
+		Namespace:    "public",
+		RelationName: "widgets",
+	})
+
+	streamStart := make([]byte, 1+5)
+	streamStart[0] = streamStartByteID
+	binary.BigEndian.PutUint32(streamStart[1:], 42)
+	streamStart[5] = 1
+	if err := s.handleWALData(context.Background(), pglogrepl.XLogData{WALData: streamStart}); err != nil {
+		t.Fatalf("handle stream start: %v", err)
+	}
+	if s.streamXid != 42 {
+		t.Fatalf("streamXid = %d after StreamStart, want 42", s.streamXid)
+	}
+
+	if err := s.handleWALData(context.Background(), pglogrepl.XLogData{WALData: []byte{streamStopByteID}}); err != nil {
+		t.Fatalf("handle stream stop: %v", err)
+	}
+	if s.streamXid != 0 {
+		t.Fatalf("streamXid = %d after StreamStop, want 0", s.streamXid)
+	}
+
+	// An ordinary transaction's row change, interleaved while xid 42's
+	// stream is paused, must be delivered immediately rather than buffered
+	// under the paused xid.
+	if err := s.deliverOrBuffer(context.Background(), InsertEvent{Table: "public.widgets"}, 0); err != nil {
+		t.Fatalf("deliverOrBuffer: %v", err)
+	}
+	if len(delivered) != 1 {
+		t.Fatalf("delivered = %d events, want 1 (interleaved insert should not be buffered)", len(delivered))
+	}
+	if _, buffered := s.streamBuffer.pending[42]; buffered {
+		t.Fatalf("interleaved insert was buffered under stale streamXid 42")
+	}
+}